@@ -2,10 +2,7 @@ package main
 
 import (
 	"fmt"
-	"io"
 	"net/http"
-	"os"
-	"path/filepath"
 
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
 	"github.com/google/uuid"
@@ -43,27 +40,6 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 
 	mediaType := fileHeader.Header.Get("Content-Type")
 
-	// Create a unique file path
-	pathToFile := filepath.Join(cfg.assetsRoot, fmt.Sprintf("%s.%s", videoID.String(), mimeToExt(mediaType)))
-
-	// Create the file
-	newFile, err := os.Create(pathToFile)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't create file", err)
-		return
-	}
-	defer newFile.Close()
-
-	// Copy the contents from the multipart.File to the new file on disk using io.Copy
-	_, err = io.Copy(newFile, file)
-	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't save file", err)
-		return
-	}
-
-	// Update the thumbnail URL in the database
-	thumbnailURL := fmt.Sprintf("http://localhost:%s/assets/%s", cfg.port, filepath.Base(pathToFile))
-
 	video, err := cfg.db.GetVideo(videoID)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't get video metadata", err)
@@ -75,6 +51,16 @@ func (cfg *apiConfig) handlerUploadThumbnail(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// Upload through the configured FileStore backend instead of writing
+	// directly to disk, so this works the same whether we're on local disk,
+	// S3, or an S3-compatible provider.
+	key := fmt.Sprintf("thumbnails/%s.%s", videoID.String(), mimeToExt(mediaType))
+	thumbnailURL, err := cfg.fileStore.Put(r.Context(), key, file, mediaType)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save file", err)
+		return
+	}
+
 	video.ThumbnailURL = &thumbnailURL
 
 	err = cfg.db.UpdateVideo(video)
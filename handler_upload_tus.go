@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/tus"
+	"github.com/google/uuid"
+)
+
+var errTusUploadNotFound = errors.New("upload not found")
+
+// handlerTusCreate implements the tus "creation" extension: POST /api/uploads
+// starts a new resumable upload and returns its location. The client is
+// expected to have already created the video record via POST /api/videos.
+func (cfg *apiConfig) handlerTusCreate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tus.ResumableVersion)
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return
+	}
+
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		respondWithError(w, http.StatusBadRequest, "Missing or invalid Upload-Length header", err)
+		return
+	}
+
+	metadata := tus.ParseMetadata(r.Header.Get("Upload-Metadata"))
+	videoID, err := uuid.Parse(metadata["videoID"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Upload-Metadata must include a valid videoID", err)
+		return
+	}
+
+	video, err := cfg.db.GetVideo(videoID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get video", err)
+		return
+	}
+	if video.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Video not found", nil)
+		return
+	}
+
+	upload, err := cfg.tusStore.Create(videoID, userID, length, metadata)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create upload", err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/uploads/"+upload.ID.String())
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handlerTusHead implements HEAD /api/uploads/{uploadID}, reporting how many
+// bytes the server has received so the client knows where to resume from.
+func (cfg *apiConfig) handlerTusHead(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tus.ResumableVersion)
+	w.Header().Set("Cache-Control", "no-store")
+
+	upload, err := cfg.getTusUpload(w, r)
+	if err != nil {
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlerTusPatch implements PATCH /api/uploads/{uploadID}, appending a chunk
+// of octet-stream data at Upload-Offset. Once the upload is complete, the
+// assembled file is handed off to the same processing pipeline a direct
+// upload uses.
+func (cfg *apiConfig) handlerTusPatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tus.ResumableVersion)
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Content-Type must be application/offset+octet-stream", nil)
+		return
+	}
+
+	upload, err := cfg.getTusUpload(w, r)
+	if err != nil {
+		return
+	}
+
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Missing or invalid Upload-Offset header", err)
+		return
+	}
+
+	chunk, err := io.ReadAll(http.MaxBytesReader(w, r.Body, upload.Length-offset))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't read chunk body", err)
+		return
+	}
+
+	upload, err = cfg.tusStore.AppendChunk(upload.ID, offset, chunk)
+	if err != nil {
+		respondWithError(w, http.StatusConflict, "Couldn't append chunk", err)
+		return
+	}
+
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+
+	if upload.Done() {
+		if _, err := cfg.jobQueue.Enqueue(videoProcessingJobType, videoProcessingJob{
+			VideoID:      upload.VideoID,
+			UserID:       upload.UserID,
+			TempFilePath: cfg.tusStore.Path(upload),
+			UploadID:     upload.ID,
+		}); err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't enqueue processing job", err)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getTusUpload resolves the {uploadID} path value, checks the caller owns
+// it, and writes an error response (returning a non-nil error) on failure.
+func (cfg *apiConfig) getTusUpload(w http.ResponseWriter, r *http.Request) (tus.Upload, error) {
+	uploadID, err := uuid.Parse(r.PathValue("uploadID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Invalid upload ID", err)
+		return tus.Upload{}, err
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't find JWT", err)
+		return tus.Upload{}, err
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.jwtSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "Couldn't validate JWT", err)
+		return tus.Upload{}, err
+	}
+
+	upload, ok, err := cfg.tusStore.Get(uploadID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get upload", err)
+		return tus.Upload{}, err
+	}
+	if !ok || upload.UserID != userID {
+		respondWithError(w, http.StatusNotFound, "Upload not found", nil)
+		return tus.Upload{}, errTusUploadNotFound
+	}
+
+	return upload, nil
+}
@@ -2,22 +2,46 @@ package main
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"math/big"
 	"mime"
 	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
 
-	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/auth"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/jobs"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/transcode"
 	"github.com/google/uuid"
 )
 
+const videoProcessingJobType = "process_video"
+
+// videoProcessingJob is the payload enqueued once an uploaded MP4 has been
+// written to a temp file, and processed asynchronously by
+// cfg.processVideoUpload so the upload request doesn't block on ffmpeg.
+// UploadID is set when TempFilePath is a completed tus upload's backing
+// file, so processVideoUpload knows to clean it up via cfg.tusStore.Delete
+// instead of just removing the file.
+type videoProcessingJob struct {
+	VideoID      uuid.UUID `json:"video_id"`
+	UserID       uuid.UUID `json:"user_id"`
+	TempFilePath string    `json:"temp_file_path"`
+	UploadID     uuid.UUID `json:"upload_id,omitempty"`
+}
+
+// registerJobHandlers wires every background job type this module knows how
+// to run onto worker.
+func (cfg *apiConfig) registerJobHandlers(worker *jobs.Worker) {
+	worker.Handle(videoProcessingJobType, cfg.processVideoUpload)
+}
+
 func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request) {
 	// Upload limit
 	r.Body = http.MaxBytesReader(w, r.Body, 1<<30) // 1GB
@@ -58,7 +82,6 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusBadRequest, "Couldn't get file from form", err)
 		return
 	}
-
 	defer videoFile.Close()
 
 	// Validate the video
@@ -69,59 +92,212 @@ func (cfg *apiConfig) handlerUploadVideo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	switch mediaType {
-	case "video/mp4":
-		// Save uploaded file to temporary file on disk
-		tempFile, err := os.CreateTemp(cfg.assetsRoot, "tubely-upload.mp4")
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't create temp file", err)
+	if mediaType != "video/mp4" {
+		respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported video format", nil)
+		return
+	}
+
+	// Save uploaded file to a temp file on disk. Everything from here on
+	// (faststart remuxing, aspect ratio detection, thumbnail extraction, HLS
+	// transcoding, and the upload itself) happens out of the request path.
+	tempFile, err := os.CreateTemp(cfg.tempRoot, "tubely-upload-*.mp4")
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't create temp file", err)
+		return
+	}
+	defer tempFile.Close()
+
+	if _, err := io.Copy(tempFile, videoFile); err != nil {
+		os.Remove(tempFile.Name())
+		respondWithError(w, http.StatusInternalServerError, "Couldn't save uploaded file", err)
+		return
+	}
+
+	job, err := cfg.jobQueue.Enqueue(videoProcessingJobType, videoProcessingJob{
+		VideoID:      videoID,
+		UserID:       userID,
+		TempFilePath: tempFile.Name(),
+	})
+	if err != nil {
+		os.Remove(tempFile.Name())
+		respondWithError(w, http.StatusInternalServerError, "Couldn't enqueue processing job", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusAccepted, map[string]string{"jobID": job.ID.String()})
+}
+
+// processVideoUpload is the job handler that turns a raw uploaded MP4 into a
+// playable HLS ladder: faststart remux, aspect-ratio detection, thumbnail
+// extraction, transcoding to the configured rendition ladder, upload of
+// every segment/playlist, and a DB update pointing at the master playlist.
+func (cfg *apiConfig) processVideoUpload(ctx context.Context, payload json.RawMessage) error {
+	var job videoProcessingJob
+	if err := json.Unmarshal(payload, &job); err != nil {
+		return fmt.Errorf("couldn't unmarshal video processing job: %w", err)
+	}
+	defer func() {
+		if job.UploadID != uuid.Nil {
+			if err := cfg.tusStore.Delete(job.UploadID); err != nil {
+				log.Printf("couldn't delete tus upload %s: %v", job.UploadID, err)
+			}
 			return
 		}
-		defer os.Remove(tempFile.Name())
-		defer tempFile.Close()
+		os.Remove(job.TempFilePath)
+	}()
 
-		io.Copy(tempFile, videoFile)
+	video, err := cfg.db.GetVideo(job.VideoID)
+	if err != nil {
+		return fmt.Errorf("couldn't get video %s: %w", job.VideoID, err)
+	}
 
-		// Reset the file pointer to the beginning of the file
-		tempFile.Seek(0, io.SeekStart)
+	faststartPath := job.TempFilePath + ".faststart.mp4"
+	if err := transcode.Faststart(ctx, job.TempFilePath, faststartPath); err != nil {
+		return err
+	}
+	defer os.Remove(faststartPath)
 
-		// Upload to S3
-		s3Key := make([]byte, 32)
-		_, err = rand.Read(s3Key)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't generate random S3 key", err)
-			return
+	aspectRatio, err := getVideoAspectRatio(faststartPath)
+	if err != nil {
+		return fmt.Errorf("couldn't determine aspect ratio: %w", err)
+	}
+	prefix := aspectRatioPrefix(aspectRatio)
+
+	if video.ThumbnailURL == nil {
+		if err := cfg.generateAndUploadThumbnail(ctx, &video, faststartPath); err != nil {
+			return err
 		}
+	}
+
+	outDir, err := os.MkdirTemp(cfg.tempRoot, "tubely-hls-*")
+	if err != nil {
+		return fmt.Errorf("couldn't create HLS output directory: %w", err)
+	}
+	defer os.RemoveAll(outDir)
 
-		s3KeyString := base64.RawURLEncoding.EncodeToString(s3Key) + ".mp4"
-		_, err = cfg.s3Client.PutObject(r.Context(), &s3.PutObjectInput{
-			Bucket:      &cfg.s3Bucket,
-			Key:         &s3KeyString,
-			Body:        tempFile,
-			ContentType: &mediaType,
-		})
+	result, err := transcode.TranscodeToHLS(ctx, faststartPath, outDir, cfg.encoderLadder, cfg.hlsSegmentSeconds)
+	if err != nil {
+		return err
+	}
+
+	s3Prefix := fmt.Sprintf("videos/%s/%s", prefix, job.VideoID)
+	masterPlaylistURL, err := cfg.uploadHLSOutput(ctx, outDir, s3Prefix, result)
+	if err != nil {
+		return err
+	}
+
+	video.VideoURL = &masterPlaylistURL
+	if err := cfg.db.UpdateVideo(video); err != nil {
+		return fmt.Errorf("couldn't update video metadata: %w", err)
+	}
+
+	jobs.RunBackgroundJob(context.Background(), "cdn-invalidation", func(ctx context.Context) error {
+		return cfg.invalidateCDN(ctx, s3Prefix)
+	})
+
+	return nil
+}
+
+// uploadHLSOutput walks outDir (produced by transcode.TranscodeToHLS) and
+// uploads every playlist and segment under s3Prefix, returning the master
+// playlist's URL.
+func (cfg *apiConfig) uploadHLSOutput(ctx context.Context, outDir, s3Prefix string, result *transcode.Result) (string, error) {
+	var masterPlaylistURL string
+
+	err := filepath.Walk(outDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't upload to S3", err)
-			return
+			return err
+		}
+		if info.IsDir() {
+			return nil
 		}
 
-		// Update the video URL in the database
-		videoURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", cfg.s3Bucket, cfg.s3Region, s3KeyString)
+		relPath, err := filepath.Rel(outDir, path)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s/%s", s3Prefix, filepath.ToSlash(relPath))
 
-		fmt.Printf("Uploaded video to S3 at location %s\n", videoURL)
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
 
-		video.VideoURL = &videoURL
-		err = cfg.db.UpdateVideo(video)
+		url, err := cfg.fileStore.Put(ctx, key, file, contentTypeFor(path))
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't update video metadata", err)
-			return
+			return err
 		}
 
-		respondWithJSON(w, http.StatusOK, map[string]string{"videoURL": videoURL})
-		return
+		if path == result.MasterPlaylist {
+			masterPlaylistURL = url
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't upload HLS output: %w", err)
+	}
+	if masterPlaylistURL == "" {
+		return "", fmt.Errorf("master playlist was not uploaded")
+	}
+	return masterPlaylistURL, nil
+}
+
+func contentTypeFor(path string) string {
+	switch filepath.Ext(path) {
+	case ".m3u8":
+		return "application/vnd.apple.mpegurl"
+	case ".ts":
+		return "video/mp2t"
 	default:
-		respondWithError(w, http.StatusUnsupportedMediaType, "Unsupported video format", err)
-		return
+		return "application/octet-stream"
+	}
+}
+
+// generateAndUploadThumbnail extracts a frame from videoPath, uploads it
+// through the configured FileStore, and sets it as the video's ThumbnailURL.
+func (cfg *apiConfig) generateAndUploadThumbnail(ctx context.Context, video *database.Video, videoPath string) error {
+	thumbnailPath := videoPath + ".thumbnail.jpg"
+	if err := transcode.ExtractThumbnail(ctx, videoPath, thumbnailPath, cfg.thumbnailAtPercent); err != nil {
+		return fmt.Errorf("couldn't extract thumbnail: %w", err)
+	}
+	defer os.Remove(thumbnailPath)
+
+	file, err := os.Open(thumbnailPath)
+	if err != nil {
+		return fmt.Errorf("couldn't open generated thumbnail: %w", err)
+	}
+	defer file.Close()
+
+	key := fmt.Sprintf("thumbnails/%s.jpg", video.ID)
+	url, err := cfg.fileStore.Put(ctx, key, file, "image/jpeg")
+	if err != nil {
+		return fmt.Errorf("couldn't upload thumbnail: %w", err)
+	}
+
+	video.ThumbnailURL = &url
+	return nil
+}
+
+// invalidateCDN requests a CloudFront invalidation for everything under
+// prefix. It's a no-op (not an error) when no distribution is configured.
+func (cfg *apiConfig) invalidateCDN(ctx context.Context, prefix string) error {
+	if cfg.cfSigner == nil {
+		return nil
+	}
+	return cfg.cfSigner.Invalidate(ctx, fmt.Sprintf("/%s/*", prefix))
+}
+
+// aspectRatioPrefix maps a detected aspect ratio to the S3 prefix uploads
+// are organized under.
+func aspectRatioPrefix(aspectRatio string) string {
+	switch aspectRatio {
+	case "16:9":
+		return "landscape"
+	case "9:16":
+		return "portrait"
+	default:
+		return "other"
 	}
 }
 
@@ -0,0 +1,131 @@
+// Package cloudfront produces CloudFront signed cookies for serving private
+// video assets (master playlists, variant playlists and segments) through a
+// distribution instead of directly from S3.
+package cloudfront
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/feature/cloudfront/sign"
+	awscf "github.com/aws/aws-sdk-go-v2/service/cloudfront"
+	"github.com/aws/aws-sdk-go-v2/service/cloudfront/types"
+	"github.com/google/uuid"
+)
+
+// Signer produces CloudFront signed cookies using an RSA key pair registered as
+// a CloudFront trusted key group, and can invalidate the distribution's edge
+// cache after new content is uploaded.
+type Signer struct {
+	distribution   string
+	distributionID string
+	keyPairID      string
+	privateKey     *rsa.PrivateKey
+}
+
+// NewSigner loads the private key at privateKeyPath and builds a Signer for
+// distribution (its domain name) using keyPairID as the registered
+// CloudFront key pair ID. distributionID is the distribution's API ID, used
+// only for cache invalidation; leave it empty to skip invalidation.
+func NewSigner(distribution, distributionID, keyPairID, privateKeyPath string) (*Signer, error) {
+	keyBytes, err := os.ReadFile(privateKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read CloudFront private key: %w", err)
+	}
+
+	block, _ := pem.Decode(keyBytes)
+	if block == nil {
+		return nil, fmt.Errorf("couldn't decode CloudFront private key PEM")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		keyAny, err2 := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err2 != nil {
+			return nil, fmt.Errorf("couldn't parse CloudFront private key: %w", err)
+		}
+		rsaKey, ok := keyAny.(*rsa.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("CloudFront private key is not an RSA key")
+		}
+		key = rsaKey
+	}
+
+	return &Signer{distribution: distribution, distributionID: distributionID, keyPairID: keyPairID, privateKey: key}, nil
+}
+
+// SignedCookies returns CloudFront signed cookies (CloudFront-Policy,
+// CloudFront-Signature, CloudFront-Key-Pair-Id) authorizing every object
+// under key's video prefix, valid for ttl. Unlike a per-URL signature, which
+// only authorizes the exact URL it's attached to, these cookies are checked
+// by CloudFront on every request to the distribution: an HLS player that
+// resolves the master playlist's relative references to variant playlists
+// and segments doesn't need each of those URLs individually signed.
+func (s *Signer) SignedCookies(key string, ttl time.Duration) ([]*http.Cookie, error) {
+	wildcardResource := fmt.Sprintf("https://%s/%s*", s.distribution, prefixOf(key))
+
+	cookieSigner := sign.NewCookieSigner(s.keyPairID, s.privateKey)
+	cookies, err := cookieSigner.SignWithPolicy(&sign.Policy{
+		Statements: []sign.Statement{
+			{
+				Resource: wildcardResource,
+				Condition: sign.Condition{
+					DateLessThan: &sign.AWSEpochTime{Time: time.Now().Add(ttl)},
+				},
+			},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't sign CloudFront cookies: %w", err)
+	}
+	return cookies, nil
+}
+
+// Invalidate requests a CloudFront cache invalidation for paths, a no-op if
+// the Signer wasn't built with a distribution ID.
+func (s *Signer) Invalidate(ctx context.Context, paths ...string) error {
+	if s.distributionID == "" {
+		return nil
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't load AWS config for CloudFront invalidation: %w", err)
+	}
+
+	client := awscf.NewFromConfig(cfg)
+	callerRef := uuid.NewString()
+	quantity := int32(len(paths))
+	_, err = client.CreateInvalidation(ctx, &awscf.CreateInvalidationInput{
+		DistributionId: &s.distributionID,
+		InvalidationBatch: &types.InvalidationBatch{
+			CallerReference: &callerRef,
+			Paths: &types.Paths{
+				Quantity: &quantity,
+				Items:    paths,
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't create CloudFront invalidation: %w", err)
+	}
+	return nil
+}
+
+// prefixOf returns everything up to and including the last "/" in key, i.e.
+// the per-video directory the key lives in.
+func prefixOf(key string) string {
+	idx := strings.LastIndex(key, "/")
+	if idx == -1 {
+		return ""
+	}
+	return key[:idx+1]
+}
@@ -0,0 +1,37 @@
+// Package filestore abstracts the object storage backend used for video and
+// thumbnail assets so the rest of the module doesn't need to know whether a
+// given asset lives in S3, an S3-compatible provider, or on local disk.
+package filestore
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// FileStore is the storage backend used to persist uploaded assets and to
+// hand back URLs clients can use to retrieve them.
+type FileStore interface {
+	// Put writes body under key and returns the URL the asset is reachable
+	// at. For backends that don't serve content publicly, the returned URL
+	// may not be usable until its key (see KeyFromURL) is passed through
+	// PresignGet.
+	Put(ctx context.Context, key string, body io.Reader, contentType string) (url string, err error)
+
+	// Delete removes the object stored under key.
+	Delete(ctx context.Context, key string) error
+
+	// PresignGet returns a time-limited URL for reading the object stored
+	// under key. Backends that serve assets publicly (e.g. local disk behind
+	// /assets) may just return the public URL unchanged.
+	PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error)
+
+	// KeyFromURL recovers the key a previous call to Put returned url for,
+	// reporting ok=false if url doesn't start with this backend's URL prefix
+	// (e.g. it belongs to a different backend). A URL that does start with
+	// that prefix is accepted as is; a query string is not stripped. Callers
+	// that need to re-derive a key from a stored URL (to presign or
+	// CloudFront-sign it) go through this instead of parsing URL formats
+	// themselves.
+	KeyFromURL(url string) (key string, ok bool)
+}
@@ -0,0 +1,66 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalStore is a FileStore for local development. It writes assets under
+// root and serves them back through the module's existing /assets file
+// server, so PresignGet just returns the public URL unchanged.
+type LocalStore struct {
+	root    string
+	baseURL string
+}
+
+// NewLocalStore builds a LocalStore rooted at root, serving assets from
+// baseURL (e.g. "http://localhost:8091/assets").
+func NewLocalStore(root, baseURL string) *LocalStore {
+	return &LocalStore{root: root, baseURL: baseURL}
+}
+
+func (l *LocalStore) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	path := filepath.Join(l.root, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("couldn't create directory for %s: %w", key, err)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return "", fmt.Errorf("couldn't create file for %s: %w", key, err)
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, body); err != nil {
+		return "", fmt.Errorf("couldn't write file for %s: %w", key, err)
+	}
+
+	return fmt.Sprintf("%s/%s", l.baseURL, key), nil
+}
+
+func (l *LocalStore) Delete(ctx context.Context, key string) error {
+	path := filepath.Join(l.root, filepath.FromSlash(key))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("couldn't delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (l *LocalStore) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	return fmt.Sprintf("%s/%s", l.baseURL, key), nil
+}
+
+// KeyFromURL recognizes a baseURL-prefixed URL this store's Put previously
+// returned.
+func (l *LocalStore) KeyFromURL(url string) (string, bool) {
+	prefix := l.baseURL + "/"
+	if strings.HasPrefix(url, prefix) {
+		return strings.TrimPrefix(url, prefix), true
+	}
+	return "", false
+}
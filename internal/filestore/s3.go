@@ -0,0 +1,97 @@
+package filestore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Store is a FileStore backed by Amazon S3 or any S3-compatible provider
+// (MinIO, DigitalOcean Spaces, Backblaze B2, ...). Compatibility providers are
+// selected by pointing Client at a custom endpoint and setting UsePathStyle,
+// see NewS3CompatibleStore.
+type S3Store struct {
+	client *s3.Client
+	bucket string
+	region string
+	// publicBaseURL, when set, is used to build the URL returned from Put
+	// instead of the default virtual-hosted-style S3 URL. This is how
+	// S3-compatible providers with their own public endpoint are exposed.
+	publicBaseURL string
+}
+
+// NewS3Store builds an S3Store for plain AWS S3, using the standard
+// virtual-hosted-style URL (https://{bucket}.s3.{region}.amazonaws.com/{key}).
+func NewS3Store(client *s3.Client, bucket, region string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, region: region}
+}
+
+// NewS3CompatibleStore builds an S3Store pointed at a non-AWS S3-compatible
+// endpoint (e.g. MinIO, Spaces, B2). publicBaseURL is the URL prefix clients
+// should use to fetch objects, e.g. "https://my-space.nyc3.digitaloceanspaces.com".
+func NewS3CompatibleStore(client *s3.Client, bucket, region, publicBaseURL string) *S3Store {
+	return &S3Store{client: client, bucket: bucket, region: region, publicBaseURL: publicBaseURL}
+}
+
+func (s *S3Store) Put(ctx context.Context, key string, body io.Reader, contentType string) (string, error) {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      &s.bucket,
+		Key:         &key,
+		Body:        body,
+		ContentType: &contentType,
+	})
+	if err != nil {
+		return "", fmt.Errorf("couldn't upload %s to S3: %w", key, err)
+	}
+
+	if s.publicBaseURL != "" {
+		return fmt.Sprintf("%s/%s", s.publicBaseURL, key), nil
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.bucket, s.region, key), nil
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	})
+	if err != nil {
+		return fmt.Errorf("couldn't delete %s from S3: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3Store) PresignGet(ctx context.Context, key string, ttl time.Duration) (string, error) {
+	presignClient := s3.NewPresignClient(s.client)
+	req, err := presignClient.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: &s.bucket,
+		Key:    &key,
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("couldn't presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// KeyFromURL recognizes a URL this store's Put previously returned: either
+// publicBaseURL-prefixed (S3-compatible providers) or the standard AWS
+// virtual-hosted-style S3 URL.
+func (s *S3Store) KeyFromURL(url string) (string, bool) {
+	if s.publicBaseURL != "" {
+		prefix := s.publicBaseURL + "/"
+		if strings.HasPrefix(url, prefix) {
+			return strings.TrimPrefix(url, prefix), true
+		}
+		return "", false
+	}
+
+	prefix := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/", s.bucket, s.region)
+	if strings.HasPrefix(url, prefix) {
+		return strings.TrimPrefix(url, prefix), true
+	}
+	return "", false
+}
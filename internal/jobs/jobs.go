@@ -0,0 +1,278 @@
+// Package jobs provides a small persistent queue for post-upload media
+// processing (aspect-ratio detection, thumbnail extraction, HLS transcoding)
+// so the upload handler can hand work off instead of doing it synchronously
+// in the request path, plus RunBackgroundJob for non-durable follow-up work
+// such as CDN invalidation.
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// State is the lifecycle state of a Job.
+type State string
+
+const (
+	StatePending State = "pending"
+	StateRunning State = "running"
+	StateDone    State = "done"
+	StateFailed  State = "failed"
+)
+
+// Job is one unit of post-upload processing work.
+type Job struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload"`
+	State     State           `json:"state"`
+	Attempts  int             `json:"attempts"`
+	NextRunAt time.Time       `json:"next_run_at"`
+	LastError string          `json:"last_error,omitempty"`
+}
+
+// Handler processes a single job's payload.
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+// Queue is a file-backed job queue, following the same flat-file-plus-mutex
+// approach as the rest of this module's persistence layer.
+type Queue struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewQueue opens (or creates) the job queue persisted at path.
+func NewQueue(path string) (*Queue, error) {
+	q := &Queue{path: path}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := q.write(map[uuid.UUID]Job{}); err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+func (q *Queue) read() (map[uuid.UUID]Job, error) {
+	data, err := os.ReadFile(q.path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read job queue: %w", err)
+	}
+	jobs := map[uuid.UUID]Job{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &jobs); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal job queue: %w", err)
+		}
+	}
+	return jobs, nil
+}
+
+func (q *Queue) write(jobs map[uuid.UUID]Job) error {
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't marshal job queue: %w", err)
+	}
+	if err := os.WriteFile(q.path, data, 0o644); err != nil {
+		return fmt.Errorf("couldn't write job queue: %w", err)
+	}
+	return nil
+}
+
+// Enqueue persists a new pending job of the given type.
+func (q *Queue) Enqueue(jobType string, payload any) (Job, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return Job{}, fmt.Errorf("couldn't marshal job payload: %w", err)
+	}
+
+	job := Job{
+		ID:        uuid.New(),
+		Type:      jobType,
+		Payload:   payloadBytes,
+		State:     StatePending,
+		NextRunAt: time.Now(),
+	}
+
+	all, err := q.read()
+	if err != nil {
+		return Job{}, err
+	}
+	all[job.ID] = job
+	if err := q.write(all); err != nil {
+		return Job{}, err
+	}
+	return job, nil
+}
+
+// claimNext picks the runnable pending job with the earliest NextRunAt and
+// marks it running. Jobs in StateFailed are never claimed.
+func (q *Queue) claimNext() (Job, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	all, err := q.read()
+	if err != nil {
+		return Job{}, false, err
+	}
+
+	var next *Job
+	for id, job := range all {
+		if job.State != StatePending || job.NextRunAt.After(time.Now()) {
+			continue
+		}
+		if next == nil || job.NextRunAt.Before(next.NextRunAt) {
+			j := job
+			j.ID = id
+			next = &j
+		}
+	}
+	if next == nil {
+		return Job{}, false, nil
+	}
+
+	next.State = StateRunning
+	all[next.ID] = *next
+	if err := q.write(all); err != nil {
+		return Job{}, false, err
+	}
+	return *next, true, nil
+}
+
+func (q *Queue) finish(id uuid.UUID, mutate func(*Job)) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	all, err := q.read()
+	if err != nil {
+		return err
+	}
+	job, ok := all[id]
+	if !ok {
+		return fmt.Errorf("job %s not found", id)
+	}
+	mutate(&job)
+	all[id] = job
+	return q.write(all)
+}
+
+// maxAttempts is how many failed runs a job gets (the first run plus its
+// retries) before it's left in StateFailed for good instead of being
+// rescheduled.
+const maxAttempts = 10
+
+// backoff is the exponential backoff schedule applied to failed jobs, capped
+// at five minutes between retries. attempts is clamped before shifting so a
+// job that somehow exceeds maxAttempts can't drive the shift past Go's
+// defined 64-bit overflow point (where 1<<uint(attempts) silently becomes 0
+// and the job would busy-loop with no delay).
+func backoff(attempts int) time.Duration {
+	if attempts > 32 {
+		attempts = 32
+	}
+	d := time.Duration(1<<uint(attempts)) * time.Second
+	if d > 5*time.Minute {
+		d = 5 * time.Minute
+	}
+	return d
+}
+
+// Worker polls the queue and dispatches jobs to the registered handler for
+// their type.
+type Worker struct {
+	queue        *Queue
+	handlers     map[string]Handler
+	pollInterval time.Duration
+}
+
+// NewWorker builds a Worker over queue that polls every pollInterval.
+func NewWorker(queue *Queue, pollInterval time.Duration) *Worker {
+	return &Worker{queue: queue, handlers: map[string]Handler{}, pollInterval: pollInterval}
+}
+
+// Handle registers fn to process jobs of the given type.
+func (w *Worker) Handle(jobType string, fn Handler) {
+	w.handlers[jobType] = fn
+}
+
+// Run polls for runnable jobs until ctx is canceled.
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			w.runOnce(ctx)
+		}
+	}
+}
+
+func (w *Worker) runOnce(ctx context.Context) {
+	for {
+		job, ok, err := w.queue.claimNext()
+		if err != nil {
+			log.Printf("jobs: couldn't claim next job: %v", err)
+			return
+		}
+		if !ok {
+			return
+		}
+
+		handler, ok := w.handlers[job.Type]
+		if !ok {
+			log.Printf("jobs: no handler registered for job type %q", job.Type)
+			w.queue.finish(job.ID, func(j *Job) {
+				j.State = StateFailed
+				j.LastError = "no handler registered"
+			})
+			continue
+		}
+
+		if err := handler(ctx, job.Payload); err != nil {
+			log.Printf("jobs: job %s (%s) failed: %v", job.ID, job.Type, err)
+			w.queue.finish(job.ID, func(j *Job) {
+				j.Attempts++
+				j.LastError = err.Error()
+				if j.Attempts >= maxAttempts {
+					// Dead-letter it: claimNext only ever picks up
+					// StatePending jobs, so this stops retrying for good.
+					j.State = StateFailed
+					return
+				}
+				j.State = StatePending
+				j.NextRunAt = time.Now().Add(backoff(j.Attempts))
+			})
+			continue
+		}
+
+		w.queue.finish(job.ID, func(j *Job) {
+			j.State = StateDone
+		})
+	}
+}
+
+// RunBackgroundJob runs fn in its own goroutine, logging name and any error
+// on completion. It's meant for fire-and-forget work that doesn't need the
+// durability of the Queue (e.g. a CDN invalidation kicked off after a job
+// queue handler finishes).
+func RunBackgroundJob(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	go func() {
+		start := time.Now()
+		if err := fn(ctx); err != nil {
+			log.Printf("jobs: background job %q failed after %s: %v", name, time.Since(start), err)
+			return
+		}
+		log.Printf("jobs: background job %q completed in %s", name, time.Since(start))
+	}()
+}
@@ -0,0 +1,27 @@
+// Package transcode wraps the ffmpeg/ffprobe invocations used to prepare an
+// uploaded video for streaming: faststart remuxing, thumbnail extraction and
+// HLS rendition encoding.
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// Faststart re-muxes inputPath into outputPath with the moov atom moved to
+// the front of the file, so players can start progressive playback before
+// the whole file has downloaded.
+func Faststart(ctx context.Context, inputPath, outputPath string) error {
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-i", inputPath,
+		"-c", "copy",
+		"-movflags", "faststart",
+		"-f", "mp4",
+		outputPath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg faststart failed: %w: %s", err, out)
+	}
+	return nil
+}
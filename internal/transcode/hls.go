@@ -0,0 +1,97 @@
+package transcode
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Rendition describes one bitrate/resolution rung of an HLS ladder.
+type Rendition struct {
+	Name             string // e.g. "360p", used as the output subdirectory
+	Width            int
+	Height           int
+	VideoBitrate     string // e.g. "800k"
+	AudioBitrate     string // e.g. "96k"
+	AudioCodec       string // e.g. "aac"
+	KeyframeInterval int    // in frames, e.g. 48
+}
+
+// Result is the output of an HLS transcode: the path to the master playlist
+// (joined onto the output directory TranscodeToHLS was given) plus the
+// per-rendition variant playlists, relative to that directory.
+type Result struct {
+	MasterPlaylist   string
+	VariantPlaylists map[string]string // rendition name -> relative playlist path
+}
+
+// TranscodeToHLS segments inputPath into an HLS ladder: one variant playlist
+// and a run of .ts segments per rendition, plus a master playlist tying them
+// together. segmentSeconds controls the target segment length.
+func TranscodeToHLS(ctx context.Context, inputPath, outDir string, renditions []Rendition, segmentSeconds int) (*Result, error) {
+	if len(renditions) == 0 {
+		return nil, fmt.Errorf("no renditions configured")
+	}
+
+	result := &Result{VariantPlaylists: make(map[string]string, len(renditions))}
+
+	// -i must stay resolvable once cmd.Dir below switches ffmpeg's working
+	// directory to outDir.
+	absInputPath, err := filepath.Abs(inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't resolve input path: %w", err)
+	}
+	args := []string{"-i", absInputPath}
+
+	var varStreamMaps []string
+	for i, r := range renditions {
+		renditionDir := filepath.Join(outDir, r.Name)
+		if err := os.MkdirAll(renditionDir, 0o755); err != nil {
+			return nil, fmt.Errorf("couldn't create rendition directory for %s: %w", r.Name, err)
+		}
+
+		args = append(args,
+			"-map", "0:v:0", "-map", "0:a:0",
+			fmt.Sprintf("-c:v:%d", i), "libx264",
+			fmt.Sprintf("-b:v:%d", i), r.VideoBitrate,
+			fmt.Sprintf("-filter:v:%d", i), fmt.Sprintf("scale=w=%d:h=%d", r.Width, r.Height),
+			fmt.Sprintf("-g:v:%d", i), strconv.Itoa(r.KeyframeInterval),
+			fmt.Sprintf("-c:a:%d", i), r.AudioCodec,
+			fmt.Sprintf("-b:a:%d", i), r.AudioBitrate,
+		)
+		varStreamMaps = append(varStreamMaps, fmt.Sprintf("v:%d,a:%d,name:%s", i, i, r.Name))
+
+		result.VariantPlaylists[r.Name] = filepath.Join(r.Name, r.Name+".m3u8")
+	}
+
+	args = append(args,
+		"-f", "hls",
+		"-hls_time", strconv.Itoa(segmentSeconds),
+		"-hls_playlist_type", "vod",
+		"-hls_segment_filename", filepath.Join("%v", "segment_%d.ts"),
+		"-master_pl_name", "master.m3u8",
+		"-var_stream_map", strings.Join(varStreamMaps, " "),
+		filepath.Join("%v", "%v.m3u8"),
+	)
+
+	// ffmpeg resolves every output path above - including where
+	// -master_pl_name ends up - relative to the process's working
+	// directory, not to outDir as a Go-side string. Pin cmd.Dir to outDir so
+	// those relative paths land where this function assumes, and so the
+	// segment/playlist references ffmpeg writes into the generated .m3u8
+	// files are themselves relative (required for the uploaded HLS tree to
+	// resolve correctly from S3/CloudFront instead of embedding this host's
+	// local filesystem paths).
+	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	cmd.Dir = outDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("ffmpeg HLS transcode failed: %w: %s", err, out)
+	}
+
+	result.MasterPlaylist = filepath.Join(outDir, "master.m3u8")
+	return result, nil
+}
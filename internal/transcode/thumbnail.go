@@ -0,0 +1,64 @@
+package transcode
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+)
+
+// duration returns the duration, in seconds, of the media file at filePath.
+func duration(ctx context.Context, filePath string) (float64, error) {
+	cmd := exec.CommandContext(ctx, "ffprobe",
+		"-v", "error",
+		"-show_entries", "format=duration",
+		"-print_format", "json",
+		filePath,
+	)
+
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Run(); err != nil {
+		return 0, fmt.Errorf("failed to run ffprobe: %w", err)
+	}
+
+	var result struct {
+		Format struct {
+			Duration string `json:"duration"`
+		} `json:"format"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		return 0, fmt.Errorf("failed to unmarshal ffprobe output: %w", err)
+	}
+
+	seconds, err := strconv.ParseFloat(result.Format.Duration, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse duration: %w", err)
+	}
+	return seconds, nil
+}
+
+// ExtractThumbnail grabs a single JPEG frame from filePath at atPercent
+// (0-1) of its duration and writes it to outPath.
+func ExtractThumbnail(ctx context.Context, filePath, outPath string, atPercent float64) error {
+	totalSeconds, err := duration(ctx, filePath)
+	if err != nil {
+		return err
+	}
+
+	seekSeconds := totalSeconds * atPercent
+	cmd := exec.CommandContext(ctx, "ffmpeg",
+		"-ss", fmt.Sprintf("%.3f", seekSeconds),
+		"-i", filePath,
+		"-vframes", "1",
+		"-q:v", "2",
+		"-y",
+		outPath,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ffmpeg thumbnail extraction failed: %w: %s", err, out)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+// Package tus implements just enough of the tus.io resumable upload
+// protocol (https://tus.io/protocols/resumable-upload) to let browsers
+// upload multi-GB videos over flaky connections: create an upload, append
+// chunks to it by offset, and report the current offset.
+package tus
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ResumableVersion is the tus protocol version this package implements.
+const ResumableVersion = "1.0.0"
+
+// Upload tracks the state of one in-progress resumable upload.
+type Upload struct {
+	ID        uuid.UUID         `json:"id"`
+	VideoID   uuid.UUID         `json:"video_id"`
+	UserID    uuid.UUID         `json:"user_id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"offset"`
+	Metadata  map[string]string `json:"metadata"`
+	FilePath  string            `json:"file_path"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+// Done reports whether every byte of the upload has been received.
+func (u Upload) Done() bool {
+	return u.Offset >= u.Length
+}
+
+// Store persists in-progress uploads, following the same flat-file-plus-mutex
+// approach as the rest of this module's persistence layer.
+type Store struct {
+	mu        sync.Mutex
+	indexPath string
+	dataDir   string
+}
+
+// NewStore opens (or creates) a tus upload store rooted at dataDir, with its
+// index persisted at indexPath.
+func NewStore(indexPath, dataDir string) (*Store, error) {
+	if err := os.MkdirAll(dataDir, 0o755); err != nil {
+		return nil, fmt.Errorf("couldn't create tus data directory: %w", err)
+	}
+	s := &Store{indexPath: indexPath, dataDir: dataDir}
+	if _, err := os.Stat(indexPath); os.IsNotExist(err) {
+		if err := s.write(map[uuid.UUID]Upload{}); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func (s *Store) read() (map[uuid.UUID]Upload, error) {
+	data, err := os.ReadFile(s.indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read tus index: %w", err)
+	}
+	uploads := map[uuid.UUID]Upload{}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &uploads); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal tus index: %w", err)
+		}
+	}
+	return uploads, nil
+}
+
+func (s *Store) write(uploads map[uuid.UUID]Upload) error {
+	data, err := json.MarshalIndent(uploads, "", "  ")
+	if err != nil {
+		return fmt.Errorf("couldn't marshal tus index: %w", err)
+	}
+	if err := os.WriteFile(s.indexPath, data, 0o644); err != nil {
+		return fmt.Errorf("couldn't write tus index: %w", err)
+	}
+	return nil
+}
+
+// Create starts a new upload of the given total length for videoID/userID,
+// storing metadata parsed from the Upload-Metadata header.
+func (s *Store) Create(videoID, userID uuid.UUID, length int64, metadata map[string]string) (Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := uuid.New()
+	upload := Upload{
+		ID:        id,
+		VideoID:   videoID,
+		UserID:    userID,
+		Length:    length,
+		Metadata:  metadata,
+		FilePath:  fmt.Sprintf("%s.bin", id.String()),
+		CreatedAt: time.Now(),
+	}
+
+	if err := os.WriteFile(s.path(upload.FilePath), nil, 0o644); err != nil {
+		return Upload{}, fmt.Errorf("couldn't create tus upload file: %w", err)
+	}
+
+	uploads, err := s.read()
+	if err != nil {
+		return Upload{}, err
+	}
+	uploads[id] = upload
+	if err := s.write(uploads); err != nil {
+		return Upload{}, err
+	}
+	return upload, nil
+}
+
+// Get returns the upload with the given ID.
+func (s *Store) Get(id uuid.UUID) (Upload, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploads, err := s.read()
+	if err != nil {
+		return Upload{}, false, err
+	}
+	upload, ok := uploads[id]
+	return upload, ok, nil
+}
+
+// Path returns the path to the upload's assembled data file under the
+// store's data directory.
+func (s *Store) Path(upload Upload) string {
+	return s.path(upload.FilePath)
+}
+
+func (s *Store) path(relPath string) string {
+	return fmt.Sprintf("%s/%s", s.dataDir, relPath)
+}
+
+// AppendChunk writes chunk to the upload's data file at its current offset
+// and advances the offset, rejecting writes that don't start at the offset
+// the client last saw (the client is out of sync and must re-issue HEAD).
+func (s *Store) AppendChunk(id uuid.UUID, atOffset int64, chunk []byte) (Upload, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploads, err := s.read()
+	if err != nil {
+		return Upload{}, err
+	}
+	upload, ok := uploads[id]
+	if !ok {
+		return Upload{}, fmt.Errorf("upload %s not found", id)
+	}
+	if atOffset != upload.Offset {
+		return Upload{}, fmt.Errorf("offset mismatch: client sent %d, server has %d", atOffset, upload.Offset)
+	}
+
+	file, err := os.OpenFile(s.path(upload.FilePath), os.O_WRONLY, 0o644)
+	if err != nil {
+		return Upload{}, fmt.Errorf("couldn't open tus upload file: %w", err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteAt(chunk, atOffset); err != nil {
+		return Upload{}, fmt.Errorf("couldn't write tus chunk: %w", err)
+	}
+
+	upload.Offset += int64(len(chunk))
+	uploads[id] = upload
+	if err := s.write(uploads); err != nil {
+		return Upload{}, err
+	}
+	return upload, nil
+}
+
+// Delete removes an upload's data file and index entry, used once the
+// processing job it was handed off to has finished with it.
+func (s *Store) Delete(id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	uploads, err := s.read()
+	if err != nil {
+		return err
+	}
+	upload, ok := uploads[id]
+	if !ok {
+		return nil
+	}
+	os.Remove(s.path(upload.FilePath))
+	delete(uploads, id)
+	return s.write(uploads)
+}
+
+// ParseMetadata decodes a tus Upload-Metadata header, a comma-separated list
+// of "key base64(value)" pairs.
+func ParseMetadata(header string) map[string]string {
+	metadata := map[string]string{}
+	if header == "" {
+		return metadata
+	}
+	for _, pair := range strings.Split(header, ",") {
+		parts := strings.SplitN(strings.TrimSpace(pair), " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(parts[1])
+		if err != nil {
+			continue
+		}
+		metadata[parts[0]] = string(value)
+	}
+	return metadata
+}
@@ -2,14 +2,23 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/cloudfront"
 	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/database"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/filestore"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/jobs"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/transcode"
+	"github.com/bootdotdev/learn-file-storage-s3-golang-starter/internal/tus"
 
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -26,28 +35,151 @@ type apiConfig struct {
 	s3CfDistribution string
 	port             string
 	s3Client         *s3.Client
+
+	fileStore filestore.FileStore
+	cfSigner  *cloudfront.Signer
+
+	encoderLadder      []transcode.Rendition
+	hlsSegmentSeconds  int
+	thumbnailAtPercent float64
+
+	jobQueue *jobs.Queue
+	tusStore *tus.Store
+
+	// tempRoot is where raw uploaded video bytes and in-progress HLS
+	// transcode output are staged while being processed. Unlike assetsRoot,
+	// it is not served by any handler.
+	tempRoot string
 }
 
-func (cfg *apiConfig) dbVideoToSignedVideo(video database.Video) (database.Video, error) {
-	// Split the video URL to get the S3 key
-	if video.VideoURL == nil || !strings.HasPrefix(*video.VideoURL, cfg.s3Bucket+",") {
-		// No video URL or not an S3 URL, return as is
-		return video, nil
+// dbVideoToSignedVideo rewrites a video's VideoURL and ThumbnailURL into
+// ones clients can actually fetch, signing each independently since they
+// live under different S3 prefixes. ttl overrides the default expiry within
+// adminMinTTL/adminMaxTTL when positive.
+func (cfg *apiConfig) dbVideoToSignedVideo(w http.ResponseWriter, video database.Video, ttl time.Duration) (database.Video, error) {
+	if ttl <= 0 {
+		ttl = 15 * time.Minute
 	}
-	s3Details := strings.Split(*video.VideoURL, ",")
-	s3Bucket := s3Details[0]
-	s3Key := s3Details[1]
+	ttl = clampTTL(ttl)
 
-	// Generate a presigned URL for the video
-	signedURL, err := generatePresignedURL(cfg.s3Client, s3Bucket, s3Key, 15*60*1e9) // 15 minutes
-	if err != nil {
-		return database.Video{}, err
+	if video.VideoURL != nil {
+		signedURL, err := cfg.signAssetURL(w, *video.VideoURL, ttl)
+		if err != nil {
+			return database.Video{}, fmt.Errorf("couldn't sign video URL: %w", err)
+		}
+		video.VideoURL = &signedURL
+	}
+
+	if video.ThumbnailURL != nil {
+		signedURL, err := cfg.signAssetURL(w, *video.ThumbnailURL, ttl)
+		if err != nil {
+			return database.Video{}, fmt.Errorf("couldn't sign thumbnail URL: %w", err)
+		}
+		video.ThumbnailURL = &signedURL
 	}
 
-	video.VideoURL = &signedURL
 	return video, nil
 }
 
+// signAssetURL signs a single FileStore-returned URL (e.g. a video's master
+// playlist or its thumbnail), valid for ttl. rawURL is returned unchanged if
+// it isn't a URL this module manages (e.g. already a fully public local
+// asset URL).
+//
+// When a CloudFront signer is configured, an HLS master playlist references
+// its variant playlists and segments with plain relative URLs, so signing
+// just the master playlist's URL would leave every file it references
+// unauthorized. Instead we set CloudFront signed cookies on w scoped to the
+// asset's whole prefix, which CloudFront checks on every request to the
+// distribution regardless of query string, and return the plain
+// (cookie-authorized) distribution URL. Without a signer configured, we fall
+// back to an S3 presigned URL for just rawURL, which is the pre-existing,
+// non-HLS behavior.
+func (cfg *apiConfig) signAssetURL(w http.ResponseWriter, rawURL string, ttl time.Duration) (string, error) {
+	key, ok := cfg.keyFromURL(rawURL)
+	if !ok {
+		return rawURL, nil
+	}
+
+	if cfg.cfSigner != nil {
+		cookies, err := cfg.cfSigner.SignedCookies(key, ttl)
+		if err != nil {
+			return "", fmt.Errorf("couldn't sign CloudFront cookies: %w", err)
+		}
+		// These cookies only do anything if the browser sends them back on
+		// requests to the distribution, not to this API server - without an
+		// explicit Domain/Path they'd default to scope themselves to
+		// wherever this response is served from instead.
+		for _, cookie := range cookies {
+			cookie.Domain = cfg.s3CfDistribution
+			cookie.Path = "/"
+			http.SetCookie(w, cookie)
+		}
+		return fmt.Sprintf("https://%s/%s", cfg.s3CfDistribution, key), nil
+	}
+
+	signedURL, err := cfg.fileStore.PresignGet(context.Background(), key, ttl)
+	if err != nil {
+		return "", fmt.Errorf("couldn't presign URL: %w", err)
+	}
+	return signedURL, nil
+}
+
+const (
+	adminMinTTL = 1 * time.Minute
+	adminMaxTTL = 24 * time.Hour
+)
+
+// clampTTL keeps client-requested link lifetimes within admin-enforced
+// bounds.
+func clampTTL(ttl time.Duration) time.Duration {
+	if ttl < adminMinTTL {
+		return adminMinTTL
+	}
+	if ttl > adminMaxTTL {
+		return adminMaxTTL
+	}
+	return ttl
+}
+
+// ttlFromQuery parses the optional ?ttl= query parameter (seconds) clients
+// use to request a shorter or longer signed-link lifetime than the default.
+// An absent or invalid value returns 0, telling dbVideoToSignedVideo to fall
+// back to its default; any value clients do pass is still clamped to
+// [adminMinTTL, adminMaxTTL] by clampTTL.
+func ttlFromQuery(r *http.Request) time.Duration {
+	raw := r.URL.Query().Get("ttl")
+	if raw == "" {
+		return 0
+	}
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// keyFromURL extracts the object key from a URL previously returned by
+// cfg.fileStore, delegating to the configured backend so this doesn't need
+// to know the URL shape of whichever one is active (plain S3,
+// S3-compatible, or local disk). Falls back to recognizing the CloudFront
+// domain shape, for URLs this module already rewrote to point at the
+// distribution.
+func (cfg *apiConfig) keyFromURL(url string) (string, bool) {
+	if key, ok := cfg.fileStore.KeyFromURL(url); ok {
+		return key, true
+	}
+
+	if cfg.s3CfDistribution != "" {
+		cfPrefix := fmt.Sprintf("https://%s/", cfg.s3CfDistribution)
+		if strings.HasPrefix(url, cfPrefix) {
+			return strings.TrimPrefix(url, cfPrefix), true
+		}
+	}
+
+	return "", false
+}
+
 func main() {
 	godotenv.Load(".env")
 
@@ -102,11 +234,11 @@ func main() {
 	}
 
 	// Use config.LoadDefaultConfig to load the Shared AWS Configuration (~/.aws/config)
-	config, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(s3Region))
+	awsConfig, err := config.LoadDefaultConfig(context.TODO(), config.WithRegion(s3Region))
 	if err != nil {
 		log.Fatalf("unable to load SDK config, %v", err)
 	}
-	s3Client := s3.NewFromConfig(config)
+	s3Client := s3.NewFromConfig(awsConfig)
 
 	cfg := apiConfig{
 		db:               db,
@@ -126,6 +258,50 @@ func main() {
 		log.Fatalf("Couldn't create assets directory: %v", err)
 	}
 
+	cfg.fileStore, err = newFileStore(s3Client, s3Bucket, s3Region, assetsRoot, port)
+	if err != nil {
+		log.Fatalf("Couldn't set up storage backend: %v", err)
+	}
+
+	cfg.cfSigner = newCloudFrontSigner(s3CfDistribution)
+
+	cfg.encoderLadder = loadEncoderLadder()
+	cfg.hlsSegmentSeconds = envInt("HLS_SEGMENT_SECONDS", 4)
+	cfg.thumbnailAtPercent = envFloat("HLS_THUMBNAIL_PERCENT", 0.1)
+
+	// dataRoot holds the job queue, in-progress tus uploads, and raw
+	// in-flight upload/transcode staging files (cfg.tempRoot below). It must
+	// live outside assetsRoot: assetsRoot is served unauthenticated at
+	// /assets, and all of this (job payloads with video/user IDs, and the
+	// pre-publication video bytes themselves) would otherwise be fetchable by
+	// anyone without a JWT, bypassing every signed-URL check this module
+	// adds.
+	dataRoot := envString("DATA_ROOT", filepath.Join(assetsRoot, "..", "data"))
+	if err := os.MkdirAll(dataRoot, 0o755); err != nil {
+		log.Fatalf("Couldn't create data directory: %v", err)
+	}
+
+	cfg.jobQueue, err = jobs.NewQueue(filepath.Join(dataRoot, "jobs.json"))
+	if err != nil {
+		log.Fatalf("Couldn't open job queue: %v", err)
+	}
+
+	cfg.tusStore, err = tus.NewStore(filepath.Join(dataRoot, "uploads.json"), filepath.Join(dataRoot, "uploads"))
+	if err != nil {
+		log.Fatalf("Couldn't open tus upload store: %v", err)
+	}
+
+	cfg.tempRoot = filepath.Join(dataRoot, "tmp")
+	if err := os.MkdirAll(cfg.tempRoot, 0o755); err != nil {
+		log.Fatalf("Couldn't create temp directory: %v", err)
+	}
+
+	worker := jobs.NewWorker(cfg.jobQueue, 2*time.Second)
+	cfg.registerJobHandlers(worker)
+	workerCtx, cancelWorker := context.WithCancel(context.Background())
+	defer cancelWorker()
+	go worker.Run(workerCtx)
+
 	mux := http.NewServeMux()
 	appHandler := http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))
 	mux.Handle("/app/", appHandler)
@@ -146,6 +322,10 @@ func main() {
 	mux.HandleFunc("GET /api/videos/{videoID}", cfg.handlerVideoGet)
 	mux.HandleFunc("DELETE /api/videos/{videoID}", cfg.handlerVideoMetaDelete)
 
+	mux.HandleFunc("POST /api/uploads", cfg.handlerTusCreate)
+	mux.HandleFunc("HEAD /api/uploads/{uploadID}", cfg.handlerTusHead)
+	mux.HandleFunc("PATCH /api/uploads/{uploadID}", cfg.handlerTusPatch)
+
 	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
 
 	srv := &http.Server{
@@ -156,3 +336,111 @@ func main() {
 	log.Printf("Serving on: http://localhost:%s/app/\n", port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// newFileStore selects a FileStore implementation based on STORAGE_BACKEND
+// (s3 | s3compatible | local), defaulting to s3 to match existing behavior.
+func newFileStore(s3Client *s3.Client, bucket, region, assetsRoot, port string) (filestore.FileStore, error) {
+	backend := os.Getenv("STORAGE_BACKEND")
+	if backend == "" {
+		backend = "s3"
+	}
+
+	switch backend {
+	case "s3":
+		return filestore.NewS3Store(s3Client, bucket, region), nil
+	case "s3compatible":
+		endpoint := os.Getenv("AWS_ENDPOINT_URL")
+		if endpoint == "" {
+			return nil, fmt.Errorf("AWS_ENDPOINT_URL must be set when STORAGE_BACKEND=s3compatible")
+		}
+		publicBaseURL := os.Getenv("STORAGE_PUBLIC_BASE_URL")
+		if publicBaseURL == "" {
+			publicBaseURL = endpoint + "/" + bucket
+		}
+
+		usePathStyle := os.Getenv("STORAGE_USE_PATH_STYLE") == "true"
+		compatClient := s3.New(s3Client.Options(), func(o *s3.Options) {
+			o.BaseEndpoint = &endpoint
+			o.UsePathStyle = usePathStyle
+		})
+		return filestore.NewS3CompatibleStore(compatClient, bucket, region, publicBaseURL), nil
+	case "local":
+		return filestore.NewLocalStore(assetsRoot, fmt.Sprintf("http://localhost:%s/assets", port)), nil
+	default:
+		return nil, fmt.Errorf("unrecognized STORAGE_BACKEND %q", backend)
+	}
+}
+
+// newCloudFrontSigner builds a CloudFront signer when CF_KEY_PAIR_ID and
+// CF_PRIVATE_KEY_PATH are both set, returning nil (meaning "fall back to S3
+// presigned URLs") otherwise.
+func newCloudFrontSigner(distribution string) *cloudfront.Signer {
+	keyPairID := os.Getenv("CF_KEY_PAIR_ID")
+	privateKeyPath := os.Getenv("CF_PRIVATE_KEY_PATH")
+	if keyPairID == "" || privateKeyPath == "" {
+		return nil
+	}
+	distributionID := os.Getenv("CF_DISTRIBUTION_ID")
+
+	signer, err := cloudfront.NewSigner(distribution, distributionID, keyPairID, privateKeyPath)
+	if err != nil {
+		log.Printf("CloudFront signing disabled, couldn't load key pair: %v", err)
+		return nil
+	}
+	return signer
+}
+
+// loadEncoderLadder builds the HLS rendition ladder from env, falling back
+// to a 360p/720p/1080p default if nothing is configured.
+func loadEncoderLadder() []transcode.Rendition {
+	defaults := []transcode.Rendition{
+		{Name: "360p", Width: 640, Height: 360, VideoBitrate: "800k", AudioBitrate: "96k", AudioCodec: "aac", KeyframeInterval: 48},
+		{Name: "720p", Width: 1280, Height: 720, VideoBitrate: "2800k", AudioBitrate: "128k", AudioCodec: "aac", KeyframeInterval: 48},
+		{Name: "1080p", Width: 1920, Height: 1080, VideoBitrate: "5000k", AudioBitrate: "192k", AudioCodec: "aac", KeyframeInterval: 48},
+	}
+
+	ladder := make([]transcode.Rendition, 0, len(defaults))
+	for _, r := range defaults {
+		prefix := "HLS_" + strings.ToUpper(r.Name) + "_"
+		if os.Getenv(prefix+"DISABLE") == "true" {
+			continue
+		}
+		r.VideoBitrate = envString(prefix+"VIDEO_BITRATE", r.VideoBitrate)
+		r.AudioBitrate = envString(prefix+"AUDIO_BITRATE", r.AudioBitrate)
+		r.AudioCodec = envString(prefix+"AUDIO_CODEC", r.AudioCodec)
+		r.KeyframeInterval = envInt(prefix+"KEYFRAME_INTERVAL", r.KeyframeInterval)
+		ladder = append(ladder, r)
+	}
+	return ladder
+}
+
+func envString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func envInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+func envFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}